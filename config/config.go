@@ -10,9 +10,9 @@ import (
 
 // Config represents the application configuration
 type Config struct {
-	Server     ServerConfig  `yaml:"server"`
-	Monitoring MonitorConfig `yaml:"monitoring"`
-	Targets    []Target      `yaml:"targets"`
+	Server     ServerConfig      `yaml:"server"`
+	Modules    map[string]Module `yaml:"modules"`
+	Enrichment EnrichmentConfig  `yaml:"enrichment"`
 }
 
 // ServerConfig contains HTTP server configuration
@@ -20,19 +20,35 @@ type ServerConfig struct {
 	Port int `yaml:"port"`
 }
 
-// MonitorConfig contains monitoring configuration
-type MonitorConfig struct {
-	Interval   time.Duration `yaml:"interval"`
-	Timeout    time.Duration `yaml:"timeout"`
-	HopTimeout time.Duration `yaml:"hop_timeout"`
+// Module describes a named probe configuration selectable via the
+// `/probe?target=...&module=...` endpoint, mirroring blackbox_exporter's
+// module concept so Prometheus scrape_configs can drive per-target probes.
+// Only IPv4 targets are supported; all three probers resolve target as an
+// A record and send to it over IPv4.
+type Module struct {
+	Prober       string        `yaml:"prober"`
+	Timeout      time.Duration `yaml:"timeout"`
+	HopTimeout   time.Duration `yaml:"hop_timeout"`
+	MaxHops      int           `yaml:"max_hops"`
+	FirstHop     int           `yaml:"first_hop"`
+	StartPort    int           `yaml:"start_port"`
+	Retries      int           `yaml:"retries"`
+	ProbesPerHop int           `yaml:"probes_per_hop"`
+	// ParisFlows enables Paris-traceroute multipath probing when > 1: the
+	// UDP prober runs this many flows, each with a fixed source/destination
+	// port pair, to discover genuine ECMP route diversity. Left at its
+	// zero value, the UDP prober keeps its classic varying-port-per-hop
+	// behavior.
+	ParisFlows int `yaml:"paris_flows"`
 }
 
-// Target represents a traceroute target
-type Target struct {
-	Host      string `yaml:"host"`
-	Name      string `yaml:"name"`
-	MaxHops   int    `yaml:"max_hops"`
-	StartPort int    `yaml:"start_port"`
+// EnrichmentConfig points at the MaxMind GeoLite2 databases used to
+// attach ASN and city/country information to hop IPs. Either path may be
+// left empty to skip that database; both are reloaded on SIGHUP so
+// operators don't need to restart the exporter when GeoLite updates ship.
+type EnrichmentConfig struct {
+	ASNDatabasePath  string `yaml:"asn_database_path"`
+	CityDatabasePath string `yaml:"city_database_path"`
 }
 
 // LoadConfig loads configuration from YAML file
@@ -51,26 +67,54 @@ func LoadConfig(filename string) (*Config, error) {
 	if config.Server.Port == 0 {
 		config.Server.Port = 9655
 	}
-	if config.Monitoring.Interval == 0 {
-		config.Monitoring.Interval = 60 * time.Second
-	}
-	if config.Monitoring.Timeout == 0 {
-		config.Monitoring.Timeout = 30 * time.Second
-	}
-	if config.Monitoring.HopTimeout == 0 {
-		config.Monitoring.HopTimeout = 5 * time.Second
-	}
 
-	// Set default values for targets
-	for i := range config.Targets {
-		if config.Targets[i].MaxHops == 0 {
-			config.Targets[i].MaxHops = 30
+	// Set default values for modules
+	for name, module := range config.Modules {
+		if module.Prober == "" {
+			module.Prober = "udp"
+		}
+		if module.Timeout == 0 {
+			module.Timeout = 30 * time.Second
+		}
+		if module.HopTimeout == 0 {
+			module.HopTimeout = 5 * time.Second
+		}
+		if module.MaxHops == 0 {
+			module.MaxHops = 30
 		}
-		if config.Targets[i].StartPort == 0 {
-			config.Targets[i].StartPort = 33434
+		if module.FirstHop == 0 {
+			module.FirstHop = 1
 		}
-		if config.Targets[i].Name == "" {
-			config.Targets[i].Name = config.Targets[i].Host
+		if module.StartPort == 0 {
+			if module.Prober == "tcp" {
+				module.StartPort = 443
+			} else {
+				module.StartPort = 33434
+			}
+		}
+		if module.Retries == 0 {
+			module.Retries = 1
+		}
+		if module.ProbesPerHop == 0 {
+			module.ProbesPerHop = 1
+		}
+		config.Modules[name] = module
+	}
+
+	// Always provide a default "udp" module so /probe works out of the box
+	if config.Modules == nil {
+		config.Modules = make(map[string]Module)
+	}
+	if _, ok := config.Modules["udp"]; !ok {
+		config.Modules["udp"] = Module{
+			Prober:       "udp",
+			Timeout:      30 * time.Second,
+			HopTimeout:   5 * time.Second,
+			MaxHops:      30,
+			FirstHop:     1,
+			StartPort:    33434,
+			Retries:      1,
+			ProbesPerHop: 1,
 		}
 	}
 
@@ -1,144 +1,78 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/ys3669/traceroute-exporter/config"
 	"github.com/ys3669/traceroute-exporter/traceroute"
+	"github.com/ys3669/traceroute-exporter/traceroute/enrich"
 )
 
-var (
-	// Traceroute hop response time in seconds
-	tracerouteHopRTT = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Name: "traceroute_hop_rtt_seconds",
-			Help: "Response time for each hop in traceroute",
-		},
-		[]string{"target", "target_name", "hop", "hop_ip"},
-	)
-
-	// Traceroute hop success/failure
-	tracerouteHopSuccess = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Name: "traceroute_hop_success",
-			Help: "Hop success (1 = success, 0 = failure)",
-		},
-		[]string{"target", "target_name", "hop", "hop_ip"},
-	)
-
-	// Traceroute hop timeout
-	tracerouteHopTimeout = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Name: "traceroute_hop_timeout",
-			Help: "Hop timeout (1 = timeout, 0 = no timeout)",
-		},
-		[]string{"target", "target_name", "hop"},
-	)
-
-	// Total hops in traceroute
-	tracerouteTotalHops = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Name: "traceroute_total_hops",
-			Help: "Total number of hops in traceroute",
-		},
-		[]string{"target", "target_name"},
-	)
-
-	// Route hash for change detection
-	tracerouteRouteHash = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Name: "traceroute_route_hash",
-			Help: "Hash of the route for change detection",
-		},
-		[]string{"target", "target_name"},
-	)
-
-	// Traceroute execution time
-	tracerouteExecutionSeconds = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Name: "traceroute_execution_seconds",
-			Help: "Time taken to complete traceroute",
-		},
-		[]string{"target", "target_name"},
-	)
-
-	// Total traceroute executions
-	tracerouteExecutionTotal = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "traceroute_execution_total",
-			Help: "Total number of traceroute executions",
-		},
-		[]string{"target", "target_name", "status"},
-	)
-)
+// customRegistry backs /metrics, which is reserved for exporter internals
+// (build_info, Go runtime stats if added later) -- not per-target
+// traceroute data, which /probe serves from its own per-request registry.
+var customRegistry = prometheus.NewRegistry()
 
-var (
-	// Custom registry without Go runtime metrics
-	customRegistry = prometheus.NewRegistry()
-)
+var buildInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "traceroute_exporter_build_info",
+	Help: "Build information about the traceroute exporter, value always 1.",
+}, []string{"goversion"})
 
 func init() {
-	// Register metrics with custom registry
-	customRegistry.MustRegister(tracerouteHopRTT)
-	customRegistry.MustRegister(tracerouteHopSuccess)
-	customRegistry.MustRegister(tracerouteHopTimeout)
-	customRegistry.MustRegister(tracerouteTotalHops)
-	customRegistry.MustRegister(tracerouteRouteHash)
-	customRegistry.MustRegister(tracerouteExecutionSeconds)
-	customRegistry.MustRegister(tracerouteExecutionTotal)
+	customRegistry.MustRegister(buildInfo)
+	buildInfo.WithLabelValues(runtime.Version()).Set(1)
 }
 
-// updateMetrics updates Prometheus metrics based on traceroute result
-func updateMetrics(result *traceroute.TracerouteResult) {
-	baseLabels := prometheus.Labels{
-		"target":      result.Target,
-		"target_name": result.TargetName,
-	}
+// probeHandler returns an http.HandlerFunc implementing the
+// blackbox-exporter-style /probe endpoint: it resolves the requested
+// module, runs the matching Prober against a fresh per-request registry,
+// and serves the resulting metrics.
+func probeHandler(cfg *config.Config, logger *log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		target := r.URL.Query().Get("target")
+		if target == "" {
+			http.Error(w, "target parameter is missing", http.StatusBadRequest)
+			return
+		}
 
-	// Update execution metrics
-	tracerouteExecutionSeconds.With(baseLabels).Set(result.Duration.Seconds())
-	tracerouteTotalHops.With(baseLabels).Set(float64(result.TotalHops))
-	tracerouteRouteHash.With(baseLabels).Set(float64(result.RouteHash))
+		moduleName := r.URL.Query().Get("module")
+		if moduleName == "" {
+			moduleName = "udp"
+		}
 
-	status := "failure"
-	if result.Success {
-		status = "success"
-	}
-	tracerouteExecutionTotal.With(prometheus.Labels{
-		"target":      result.Target,
-		"target_name": result.TargetName,
-		"status":      status,
-	}).Inc()
-
-	// Update hop metrics
-	for _, hop := range result.Hops {
-		hopLabels := prometheus.Labels{
-			"target":      result.Target,
-			"target_name": result.TargetName,
-			"hop":         fmt.Sprintf("%d", hop.Hop),
+		module, ok := cfg.Modules[moduleName]
+		if !ok {
+			http.Error(w, fmt.Sprintf("unknown module %q", moduleName), http.StatusBadRequest)
+			return
 		}
 
-		if hop.Success && hop.IP != nil {
-			hopLabels["hop_ip"] = hop.IP.String()
-			tracerouteHopRTT.With(hopLabels).Set(hop.RTT.Seconds())
-			tracerouteHopSuccess.With(hopLabels).Set(1)
-		} else {
-			hopLabels["hop_ip"] = ""
-			if hop.Timeout {
-				tracerouteHopTimeout.With(prometheus.Labels{
-					"target":      result.Target,
-					"target_name": result.TargetName,
-					"hop":         fmt.Sprintf("%d", hop.Hop),
-				}).Set(1)
-			}
-			tracerouteHopSuccess.With(hopLabels).Set(0)
+		prober, ok := traceroute.Probers[module.Prober]
+		if !ok {
+			http.Error(w, fmt.Sprintf("unknown prober %q", module.Prober), http.StatusBadRequest)
+			return
 		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), module.Timeout)
+		defer cancel()
+
+		registry := prometheus.NewRegistry()
+
+		start := time.Now()
+		success := prober.Probe(ctx, target, module, registry, logger)
+		logger.Printf("Probe of %s with module %q completed: success=%v, duration=%v", target, moduleName, success, time.Since(start))
+
+		promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
 	}
 }
 
@@ -153,42 +87,36 @@ func main() {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
+	logger := log.Default()
+
 	log.Printf("Starting traceroute exporter on port %d", cfg.Server.Port)
-	log.Printf("Monitoring interval: %v", cfg.Monitoring.Interval)
-	log.Printf("Traceroute timeout: %v", cfg.Monitoring.Timeout)
-	log.Printf("Hop timeout: %v", cfg.Monitoring.HopTimeout)
-
-	// Create UDP tracer
-	tracer := traceroute.NewUDPTracer(cfg.Monitoring.Timeout, cfg.Monitoring.HopTimeout)
-
-	// Start traceroute monitoring
-	go func() {
-		ticker := time.NewTicker(cfg.Monitoring.Interval)
-		defer ticker.Stop()
-
-		for {
-			for _, target := range cfg.Targets {
-				log.Printf("Tracing route to %s (%s), max_hops=%d", target.Host, target.Name, target.MaxHops)
-
-				result, err := tracer.Trace(target.Host, target.MaxHops, target.StartPort)
-				if err != nil {
-					log.Printf("Traceroute to %s failed: %v", target.Host, err)
-				} else {
-					result.TargetName = target.Name
-					log.Printf("Traceroute to %s completed: %d hops, %v", target.Host, result.TotalHops, result.Duration)
-				}
 
-				// Update metrics regardless of success/failure
-				if result != nil {
-					updateMetrics(result)
+	// Load ASN/GeoIP enrichment databases, if configured, and let SIGHUP
+	// reload them in place so operators don't need to restart the
+	// exporter when GeoLite2 updates ship.
+	if cfg.Enrichment.ASNDatabasePath != "" || cfg.Enrichment.CityDatabasePath != "" {
+		enricher, err := enrich.New(cfg.Enrichment.ASNDatabasePath, cfg.Enrichment.CityDatabasePath)
+		if err != nil {
+			log.Fatalf("Failed to load enrichment databases: %v", err)
+		}
+		traceroute.SetEnricher(enricher)
+		log.Printf("Loaded ASN/GeoIP enrichment databases")
+
+		sigCh := make(chan os.Signal, 1)
+		signal.Notify(sigCh, syscall.SIGHUP)
+		go func() {
+			for range sigCh {
+				log.Printf("Received SIGHUP, reloading ASN/GeoIP enrichment databases")
+				if err := enricher.Reload(cfg.Enrichment.ASNDatabasePath, cfg.Enrichment.CityDatabasePath); err != nil {
+					log.Printf("Failed to reload enrichment databases: %v", err)
 				}
 			}
-			<-ticker.C
-		}
-	}()
+		}()
+	}
 
 	// Setup HTTP server with custom registry
 	http.Handle("/metrics", promhttp.HandlerFor(customRegistry, promhttp.HandlerOpts{}))
+	http.HandleFunc("/probe", probeHandler(cfg, logger))
 
 	listenAddr := cfg.GetListenAddress()
 	log.Printf("Server starting on %s", listenAddr)
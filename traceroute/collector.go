@@ -0,0 +1,284 @@
+package traceroute
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// scrapeResult is the cached state for one target: its last completed
+// traceroute, the wall-clock time that scrape took, and the cumulative
+// per-status execution counts (the one piece of state here that, unlike
+// everything else TracerouteCollector emits, must persist across scrapes
+// to stay a valid Prometheus counter).
+type scrapeResult struct {
+	result         *TracerouteResult
+	scrapeDuration time.Duration
+	scrapeSuccess  bool
+	executionTotal map[string]float64 // status -> count
+}
+
+// TracerouteCollector implements prometheus.Collector, building every
+// metric fresh from each target's last completed traceroute at Collect
+// time. Because a scrape only emits label series for hops present in the
+// current result, a hop_ip that stops showing up (e.g. because the route
+// changed) simply disappears from the next scrape instead of lingering
+// in a GaugeVec forever.
+type TracerouteCollector struct {
+	mu      sync.RWMutex
+	results map[string]*scrapeResult // keyed by target
+
+	hopRTTDesc            *prometheus.Desc
+	hopRTTSummaryDesc     *prometheus.Desc
+	hopSuccessDesc        *prometheus.Desc
+	hopTimeoutDesc        *prometheus.Desc
+	hopAlternatePathsDesc *prometheus.Desc
+	hopInfoDesc           *prometheus.Desc
+	asPathLengthDesc      *prometheus.Desc
+	totalHopsDesc         *prometheus.Desc
+	routeHashDesc         *prometheus.Desc
+	pathCountDesc         *prometheus.Desc
+	executionTimeDesc     *prometheus.Desc
+	executionTotalDesc    *prometheus.Desc
+	scrapeDurationDesc    *prometheus.Desc
+	scrapeSuccessDesc     *prometheus.Desc
+}
+
+// NewTracerouteCollector creates a TracerouteCollector with no cached
+// results; call Update as each target's traceroute completes.
+func NewTracerouteCollector() *TracerouteCollector {
+	return &TracerouteCollector{
+		results: make(map[string]*scrapeResult),
+
+		hopRTTDesc: prometheus.NewDesc(
+			"traceroute_hop_rtt_seconds",
+			"Response time for each probe sent for a hop in traceroute",
+			[]string{"target", "target_name", "hop", "hop_ip", "probe"}, nil,
+		),
+		hopRTTSummaryDesc: prometheus.NewDesc(
+			"traceroute_hop_rtt_seconds_summary",
+			"Min/avg/max/stddev response time across a hop's probes, selected by the stat label",
+			[]string{"target", "target_name", "hop", "hop_ip", "stat"}, nil,
+		),
+		hopSuccessDesc: prometheus.NewDesc(
+			"traceroute_hop_success",
+			"Hop success (1 = success, 0 = failure)",
+			[]string{"target", "target_name", "hop", "hop_ip"}, nil,
+		),
+		hopTimeoutDesc: prometheus.NewDesc(
+			"traceroute_hop_timeout",
+			"Hop timeout (1 = timeout, 0 = no timeout)",
+			[]string{"target", "target_name", "hop"}, nil,
+		),
+		hopAlternatePathsDesc: prometheus.NewDesc(
+			"traceroute_hop_alternate_paths",
+			"Number of distinct IPs seen at this hop across Paris-traceroute flows",
+			[]string{"target", "target_name", "hop"}, nil,
+		),
+		hopInfoDesc: prometheus.NewDesc(
+			"traceroute_hop_info",
+			"ASN/GeoIP information for a hop's IP (always 1; info carried in labels)",
+			[]string{"target", "target_name", "hop", "hop_ip", "asn", "as_org", "country"}, nil,
+		),
+		asPathLengthDesc: prometheus.NewDesc(
+			"traceroute_as_path_length",
+			"Number of distinct autonomous systems traversed, from ASN enrichment",
+			[]string{"target", "target_name"}, nil,
+		),
+		totalHopsDesc: prometheus.NewDesc(
+			"traceroute_total_hops",
+			"Total number of hops in traceroute",
+			[]string{"target", "target_name"}, nil,
+		),
+		routeHashDesc: prometheus.NewDesc(
+			"traceroute_route_hash",
+			"Hash of the route for change detection",
+			[]string{"target", "target_name"}, nil,
+		),
+		pathCountDesc: prometheus.NewDesc(
+			"traceroute_path_count",
+			"Number of distinct end-to-end paths observed (>1 indicates genuine ECMP multipath)",
+			[]string{"target", "target_name"}, nil,
+		),
+		executionTimeDesc: prometheus.NewDesc(
+			"traceroute_execution_seconds",
+			"Time taken to complete traceroute",
+			[]string{"target", "target_name"}, nil,
+		),
+		executionTotalDesc: prometheus.NewDesc(
+			"traceroute_execution_total",
+			"Total number of traceroute executions",
+			[]string{"target", "target_name", "status"}, nil,
+		),
+		scrapeDurationDesc: prometheus.NewDesc(
+			"traceroute_scrape_duration_seconds",
+			"Time taken to complete the traceroute behind this target's last scrape",
+			[]string{"target"}, nil,
+		),
+		scrapeSuccessDesc: prometheus.NewDesc(
+			"traceroute_scrape_success",
+			"Whether the last scrape of this target completed without error (1 = success, 0 = failure)",
+			[]string{"target"}, nil,
+		),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *TracerouteCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.hopRTTDesc
+	ch <- c.hopRTTSummaryDesc
+	ch <- c.hopSuccessDesc
+	ch <- c.hopTimeoutDesc
+	ch <- c.hopAlternatePathsDesc
+	ch <- c.hopInfoDesc
+	ch <- c.asPathLengthDesc
+	ch <- c.totalHopsDesc
+	ch <- c.routeHashDesc
+	ch <- c.pathCountDesc
+	ch <- c.executionTimeDesc
+	ch <- c.executionTotalDesc
+	ch <- c.scrapeDurationDesc
+	ch <- c.scrapeSuccessDesc
+}
+
+// Update records the outcome of a completed traceroute for result.Target,
+// refreshing the cached result Collect will build metrics from on the
+// next scrape. scrapeDuration is the wall-clock time the scrape (not
+// just the traceroute itself) took.
+func (c *TracerouteCollector) Update(result *TracerouteResult, scrapeDuration time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	sr, ok := c.results[result.Target]
+	if !ok {
+		sr = &scrapeResult{executionTotal: make(map[string]float64)}
+		c.results[result.Target] = sr
+	}
+	sr.result = result
+	sr.scrapeDuration = scrapeDuration
+	sr.scrapeSuccess = result.Error == nil
+
+	status := "failure"
+	if result.Success {
+		status = "success"
+	}
+	sr.executionTotal[status]++
+}
+
+// Collect implements prometheus.Collector, emitting metrics built fresh
+// from each target's cached scrapeResult.
+func (c *TracerouteCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for target, sr := range c.results {
+		ch <- prometheus.MustNewConstMetric(c.scrapeDurationDesc, prometheus.GaugeValue, sr.scrapeDuration.Seconds(), target)
+
+		scrapeSuccessValue := 0.0
+		if sr.scrapeSuccess {
+			scrapeSuccessValue = 1.0
+		}
+		ch <- prometheus.MustNewConstMetric(c.scrapeSuccessDesc, prometheus.GaugeValue, scrapeSuccessValue, target)
+
+		for status, count := range sr.executionTotal {
+			ch <- prometheus.MustNewConstMetric(c.executionTotalDesc, prometheus.CounterValue, count, target, sr.result.TargetName, status)
+		}
+
+		if sr.result != nil {
+			c.collectResult(ch, sr.result)
+		}
+	}
+}
+
+// collectResult emits the per-traceroute metrics for a single cached
+// result: everything that isn't scrape-level bookkeeping.
+func (c *TracerouteCollector) collectResult(ch chan<- prometheus.Metric, result *TracerouteResult) {
+	target, targetName := result.Target, result.TargetName
+
+	ch <- prometheus.MustNewConstMetric(c.executionTimeDesc, prometheus.GaugeValue, result.Duration.Seconds(), target, targetName)
+	ch <- prometheus.MustNewConstMetric(c.totalHopsDesc, prometheus.GaugeValue, float64(result.TotalHops), target, targetName)
+	ch <- prometheus.MustNewConstMetric(c.routeHashDesc, prometheus.GaugeValue, float64(result.RouteHash), target, targetName)
+	ch <- prometheus.MustNewConstMetric(c.pathCountDesc, prometheus.GaugeValue, float64(result.PathCount), target, targetName)
+
+	asSeen := make(map[uint32]struct{})
+
+	for _, hop := range result.Hops {
+		hopLabel := fmt.Sprintf("%d", hop.Hop)
+
+		// Tracers that haven't adopted multi-probe hops yet still report a
+		// single aggregate result; treat it as one probe so it gets the
+		// same per-probe metric as everything else.
+		probes := hop.Probes
+		if len(probes) == 0 {
+			probes = []ProbeResult{{Probe: 1, IP: hop.IP, RTT: hop.RTT, Success: hop.Success, Timeout: hop.Timeout}}
+		}
+
+		for _, probe := range probes {
+			if !probe.Success {
+				continue
+			}
+			hopIP := ""
+			if probe.IP != nil {
+				hopIP = probe.IP.String()
+			}
+			ch <- prometheus.MustNewConstMetric(c.hopRTTDesc, prometheus.GaugeValue, probe.RTT.Seconds(),
+				target, targetName, hopLabel, hopIP, fmt.Sprintf("%d", probe.Probe))
+		}
+
+		if len(hop.Probes) > 0 {
+			c.collectRTTSummary(ch, target, targetName, hopLabel, hop)
+		}
+
+		if len(hop.AlternateIPs) > 0 {
+			ch <- prometheus.MustNewConstMetric(c.hopAlternatePathsDesc, prometheus.GaugeValue, float64(len(hop.allIPs())),
+				target, targetName, hopLabel)
+		}
+
+		if hop.Success && hop.IP != nil && hop.ASN != 0 {
+			ch <- prometheus.MustNewConstMetric(c.hopInfoDesc, prometheus.GaugeValue, 1,
+				target, targetName, hopLabel, hop.IP.String(), fmt.Sprintf("%d", hop.ASN), hop.ASOrg, hop.Country)
+			asSeen[hop.ASN] = struct{}{}
+		}
+
+		hopIP := ""
+		if hop.Success && hop.IP != nil {
+			hopIP = hop.IP.String()
+		}
+
+		if hop.Success {
+			ch <- prometheus.MustNewConstMetric(c.hopSuccessDesc, prometheus.GaugeValue, 1, target, targetName, hopLabel, hopIP)
+		} else {
+			if hop.Timeout {
+				ch <- prometheus.MustNewConstMetric(c.hopTimeoutDesc, prometheus.GaugeValue, 1, target, targetName, hopLabel)
+			}
+			ch <- prometheus.MustNewConstMetric(c.hopSuccessDesc, prometheus.GaugeValue, 0, target, targetName, hopLabel, hopIP)
+		}
+	}
+
+	if len(asSeen) > 0 {
+		ch <- prometheus.MustNewConstMetric(c.asPathLengthDesc, prometheus.GaugeValue, float64(len(asSeen)), target, targetName)
+	}
+}
+
+// collectRTTSummary emits traceroute_hop_rtt_seconds_summary for a hop's
+// min/avg/max/stddev RTT across its probes.
+func (c *TracerouteCollector) collectRTTSummary(ch chan<- prometheus.Metric, target, targetName, hopLabel string, hop HopResult) {
+	hopIP := ""
+	if hop.IP != nil {
+		hopIP = hop.IP.String()
+	}
+
+	min, avg, max, stddev := hop.RTTStats()
+	stats := map[string]time.Duration{
+		"min":    min,
+		"avg":    avg,
+		"max":    max,
+		"stddev": stddev,
+	}
+	for stat, value := range stats {
+		ch <- prometheus.MustNewConstMetric(c.hopRTTSummaryDesc, prometheus.GaugeValue, value.Seconds(),
+			target, targetName, hopLabel, hopIP, stat)
+	}
+}
@@ -0,0 +1,82 @@
+package traceroute
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestTracerouteCollectorCollect(t *testing.T) {
+	collector := NewTracerouteCollector()
+
+	result := &TracerouteResult{
+		Target:     "10.0.0.1",
+		TargetName: "example",
+		Success:    true,
+		Duration:   250 * time.Millisecond,
+		TotalHops:  1,
+		RouteHash:  42,
+		PathCount:  1,
+		Hops: []HopResult{
+			{
+				Hop:     1,
+				IP:      net.ParseIP("10.0.0.1"),
+				RTT:     10 * time.Millisecond,
+				Success: true,
+			},
+		},
+	}
+
+	collector.Update(result, 300*time.Millisecond)
+
+	expected := `
+		# HELP traceroute_hop_success Hop success (1 = success, 0 = failure)
+		# TYPE traceroute_hop_success gauge
+		traceroute_hop_success{hop="1",hop_ip="10.0.0.1",target="10.0.0.1",target_name="example"} 1
+		# HELP traceroute_scrape_success Whether the last scrape of this target completed without error (1 = success, 0 = failure)
+		# TYPE traceroute_scrape_success gauge
+		traceroute_scrape_success{target="10.0.0.1"} 1
+	`
+
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(expected),
+		"traceroute_hop_success", "traceroute_scrape_success"); err != nil {
+		t.Errorf("unexpected collected metrics:\n%v", err)
+	}
+}
+
+func TestTracerouteCollectorCollectDropsStaleHopIP(t *testing.T) {
+	collector := NewTracerouteCollector()
+
+	collector.Update(&TracerouteResult{
+		Target:     "10.0.0.1",
+		TargetName: "example",
+		Success:    true,
+		Hops: []HopResult{
+			{Hop: 1, IP: net.ParseIP("10.0.0.1"), Success: true},
+		},
+	}, time.Millisecond)
+
+	// A later scrape along a different path shouldn't leave the old
+	// hop_ip="10.0.0.1" series behind.
+	collector.Update(&TracerouteResult{
+		Target:     "10.0.0.1",
+		TargetName: "example",
+		Success:    true,
+		Hops: []HopResult{
+			{Hop: 1, IP: net.ParseIP("10.0.0.2"), Success: true},
+		},
+	}, time.Millisecond)
+
+	expected := `
+		# HELP traceroute_hop_success Hop success (1 = success, 0 = failure)
+		# TYPE traceroute_hop_success gauge
+		traceroute_hop_success{hop="1",hop_ip="10.0.0.2",target="10.0.0.1",target_name="example"} 1
+	`
+
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(expected), "traceroute_hop_success"); err != nil {
+		t.Errorf("unexpected collected metrics:\n%v", err)
+	}
+}
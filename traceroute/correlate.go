@@ -0,0 +1,90 @@
+package traceroute
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// IP protocol numbers used to identify the embedded datagram inside an
+// ICMP Time Exceeded / Destination Unreachable payload.
+const (
+	protocolICMP = 1
+	protocolTCP  = 6
+	protocolUDP  = 17
+)
+
+// probeIdentifier uniquely identifies the probe that produced an embedded
+// datagram, so that ICMP replies arriving out of order (or belonging to a
+// different in-flight probe) can be correlated to the hop that sent them.
+type probeIdentifier struct {
+	srcPort int // UDP/TCP source port, or ICMP identifier
+	seq     int // UDP/TCP destination port, or ICMP sequence number
+}
+
+// embeddedTransportHeader validates and returns the first 8 bytes of the
+// original datagram embedded inside an ICMP Time Exceeded / Destination
+// Unreachable message. Per RFC 792, that message carries:
+//
+//	bytes 0-3:  ICMP type/code/checksum
+//	bytes 4-7:  unused (or next-hop MTU for Fragmentation Needed)
+//	bytes 8+:   the original IPv4 header plus the first 8 bytes of its payload
+//
+// proto is the expected protocol number of the embedded datagram (UDP,
+// TCP, or ICMP); a mismatch means this ICMP message is a reply to someone
+// else's packet and should be ignored.
+func embeddedTransportHeader(buf []byte, proto byte) ([]byte, error) {
+	const icmpHeaderLen = 8
+	if len(buf) < icmpHeaderLen+20+8 {
+		return nil, fmt.Errorf("ICMP payload too short to contain an embedded probe")
+	}
+
+	inner := buf[icmpHeaderLen:]
+	ihl := int(inner[0]&0x0f) * 4
+	if ihl < 20 || len(inner) < ihl+8 {
+		return nil, fmt.Errorf("embedded IP header too short")
+	}
+	if inner[9] != proto {
+		return nil, fmt.Errorf("embedded protocol %d does not match expected %d", inner[9], proto)
+	}
+
+	return inner[ihl : ihl+8], nil
+}
+
+// parseEmbeddedProbe extracts the identifier of the original probe packet
+// embedded inside an ICMP Time Exceeded / Destination Unreachable message.
+func parseEmbeddedProbe(buf []byte, proto byte) (probeIdentifier, error) {
+	payload, err := embeddedTransportHeader(buf, proto)
+	if err != nil {
+		return probeIdentifier{}, err
+	}
+
+	switch proto {
+	case protocolUDP, protocolTCP:
+		// UDP and TCP headers both start with srcPort(2) dstPort(2).
+		return probeIdentifier{
+			srcPort: int(binary.BigEndian.Uint16(payload[0:2])),
+			seq:     int(binary.BigEndian.Uint16(payload[2:4])),
+		}, nil
+	case protocolICMP:
+		// Echo request: type(1) code(1) checksum(2) id(2) seq(2).
+		return probeIdentifier{
+			srcPort: int(binary.BigEndian.Uint16(payload[4:6])),
+			seq:     int(binary.BigEndian.Uint16(payload[6:8])),
+		}, nil
+	default:
+		return probeIdentifier{}, fmt.Errorf("unsupported embedded protocol %d", proto)
+	}
+}
+
+// parseEmbeddedUDPChecksum extracts the UDP checksum field from the
+// original datagram embedded in an ICMP Time Exceeded / Destination
+// Unreachable message. Paris-traceroute mode keeps ports fixed across a
+// flow's hops, so probes within a hop are instead correlated by a
+// checksum value chosen to double as an identifier (see buildParisUDP).
+func parseEmbeddedUDPChecksum(buf []byte) (uint16, error) {
+	payload, err := embeddedTransportHeader(buf, protocolUDP)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint16(payload[6:8]), nil
+}
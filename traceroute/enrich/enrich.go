@@ -0,0 +1,123 @@
+// Package enrich looks up ASN and city/country information for hop IPs
+// from MaxMind GeoLite2 .mmdb databases.
+package enrich
+
+import (
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/oschwald/geoip2-golang"
+)
+
+// Info holds the ASN/GeoIP attributes looked up for a single IP. Fields
+// are left zero-valued when the corresponding database isn't configured
+// or has no record for the IP.
+type Info struct {
+	ASN     uint32
+	ASOrg   string
+	Country string
+	City    string
+}
+
+// Enricher looks up ASN and city/country information for IPs using
+// MaxMind GeoLite2-ASN and GeoLite2-City databases. It's safe for
+// concurrent use, and its databases can be swapped out at runtime via
+// Reload (e.g. on SIGHUP) so operators don't need to restart the
+// exporter when GeoLite updates ship.
+type Enricher struct {
+	mu         sync.RWMutex
+	asnReader  *geoip2.Reader
+	cityReader *geoip2.Reader
+}
+
+// New opens the ASN and City databases at asnPath/cityPath and returns
+// an Enricher backed by them. Either path may be empty to skip that
+// database.
+func New(asnPath, cityPath string) (*Enricher, error) {
+	e := &Enricher{}
+	if err := e.Reload(asnPath, cityPath); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Reload closes the current databases (if any) and opens new ones at
+// asnPath/cityPath, swapping them in atomically. Safe to call while
+// Lookup is in use elsewhere, e.g. from a SIGHUP handler.
+func (e *Enricher) Reload(asnPath, cityPath string) error {
+	var asnReader, cityReader *geoip2.Reader
+	var err error
+
+	if asnPath != "" {
+		asnReader, err = geoip2.Open(asnPath)
+		if err != nil {
+			return fmt.Errorf("failed to open ASN database %s: %w", asnPath, err)
+		}
+	}
+	if cityPath != "" {
+		cityReader, err = geoip2.Open(cityPath)
+		if err != nil {
+			if asnReader != nil {
+				asnReader.Close()
+			}
+			return fmt.Errorf("failed to open City database %s: %w", cityPath, err)
+		}
+	}
+
+	e.mu.Lock()
+	oldASN, oldCity := e.asnReader, e.cityReader
+	e.asnReader, e.cityReader = asnReader, cityReader
+	e.mu.Unlock()
+
+	if oldASN != nil {
+		oldASN.Close()
+	}
+	if oldCity != nil {
+		oldCity.Close()
+	}
+	return nil
+}
+
+// Lookup returns the ASN/GeoIP attributes for ip using whichever
+// databases are currently loaded. A lookup miss or an unconfigured
+// database is not an error; it just leaves the corresponding fields at
+// their zero value.
+func (e *Enricher) Lookup(ip net.IP) Info {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	var info Info
+
+	if e.asnReader != nil {
+		if rec, err := e.asnReader.ASN(ip); err == nil {
+			info.ASN = uint32(rec.AutonomousSystemNumber)
+			info.ASOrg = rec.AutonomousSystemOrganization
+		}
+	}
+	if e.cityReader != nil {
+		if rec, err := e.cityReader.City(ip); err == nil {
+			info.Country = rec.Country.Names["en"]
+			info.City = rec.City.Names["en"]
+		}
+	}
+
+	return info
+}
+
+// Close releases the underlying database file handles.
+func (e *Enricher) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var err error
+	if e.asnReader != nil {
+		err = e.asnReader.Close()
+	}
+	if e.cityReader != nil {
+		if cerr := e.cityReader.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
@@ -0,0 +1,37 @@
+package traceroute
+
+import (
+	"github.com/ys3669/traceroute-exporter/traceroute/enrich"
+)
+
+// activeEnricher holds the optional ASN/GeoIP enricher configured at
+// startup via SetEnricher. It's nil when enrichment isn't configured, in
+// which case EnrichHops is a no-op.
+var activeEnricher *enrich.Enricher
+
+// SetEnricher installs e as the enricher used by EnrichHops. Passing nil
+// disables enrichment.
+func SetEnricher(e *enrich.Enricher) {
+	activeEnricher = e
+}
+
+// EnrichHops populates ASN/GeoIP fields on each successful hop of result
+// using the active enricher, if one is configured.
+func EnrichHops(result *TracerouteResult) {
+	if activeEnricher == nil || result == nil {
+		return
+	}
+
+	for i := range result.Hops {
+		hop := &result.Hops[i]
+		if !hop.Success || hop.IP == nil {
+			continue
+		}
+
+		info := activeEnricher.Lookup(hop.IP)
+		hop.ASN = info.ASN
+		hop.ASOrg = info.ASOrg
+		hop.Country = info.Country
+		hop.City = info.City
+	}
+}
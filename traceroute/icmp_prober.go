@@ -0,0 +1,35 @@
+package traceroute
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/ys3669/traceroute-exporter/config"
+)
+
+// ICMPProber runs an ICMP echo-based traceroute module. It implements Prober.
+type ICMPProber struct{}
+
+// Probe implements Prober.
+func (ICMPProber) Probe(ctx context.Context, target string, module config.Module, registry *prometheus.Registry, logger *log.Logger) bool {
+	tracer := NewICMPTracer(module.Timeout, module.HopTimeout)
+
+	start := time.Now()
+	result, err := tracer.Trace(ctx, target, module.FirstHop, module.MaxHops, module.ProbesPerHop, module.Retries)
+	if err != nil {
+		logger.Printf("ICMP traceroute to %s failed: %v", target, err)
+	}
+	if result == nil {
+		return false
+	}
+	result.TargetName = target
+	EnrichHops(result)
+
+	collector := NewTracerouteCollector()
+	collector.Update(result, time.Since(start))
+	registry.MustRegister(collector)
+
+	return result.Success
+}
@@ -0,0 +1,217 @@
+package traceroute
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+// ICMPTracer implements ICMP echo-based traceroute: it sends icmp.Echo
+// requests with increasing TTL and correlates replies (either Time
+// Exceeded from an intermediate hop, or Echo Reply from the destination)
+// by the identifier/sequence pair embedded in the probe. IPv6 targets are
+// not supported: it listens on ip4:icmp, not ip6:ipv6-icmp.
+type ICMPTracer struct {
+	timeout    time.Duration
+	hopTimeout time.Duration
+}
+
+// NewICMPTracer creates a new ICMP traceroute instance.
+func NewICMPTracer(timeout, hopTimeout time.Duration) *ICMPTracer {
+	return &ICMPTracer{
+		timeout:    timeout,
+		hopTimeout: hopTimeout,
+	}
+}
+
+// Trace performs ICMP-based traceroute to the target. probesPerHop probes
+// are fired per TTL, with up to retries additional rounds for probes that
+// don't get a response. ctx bounds the whole trace in addition to
+// t.timeout -- whichever is shorter wins -- so a caller's deadline or
+// cancellation actually stops in-flight work.
+func (t *ICMPTracer) Trace(ctx context.Context, target string, firstHop, maxHops, probesPerHop, retries int) (*TracerouteResult, error) {
+	start := time.Now()
+
+	targetAddr, err := net.ResolveIPAddr("ip4", target)
+	if err != nil {
+		return &TracerouteResult{
+			Target:   target,
+			Success:  false,
+			Duration: time.Since(start),
+			Error:    fmt.Errorf("failed to resolve target: %w", err),
+		}, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+
+	result := &TracerouteResult{
+		Target:     target,
+		TargetName: target,
+		Hops:       make([]HopResult, 0, maxHops),
+		Success:    false,
+		Duration:   0,
+	}
+
+	// The ICMP socket is used both to send our echo requests and to
+	// receive the Time Exceeded / Echo Reply responses, so TTL must be
+	// set on it per-hop before every send.
+	conn, err := icmp.ListenPacket("ip4:icmp", "0.0.0.0")
+	if err != nil {
+		return result, fmt.Errorf("failed to create ICMP socket: %w", err)
+	}
+	defer conn.Close()
+
+	id := os.Getpid() & 0xffff
+
+hopLoop:
+	for hop := firstHop; hop <= maxHops; hop++ {
+		select {
+		case <-ctx.Done():
+			result.Error = fmt.Errorf("traceroute timeout")
+			break hopLoop
+		default:
+		}
+
+		hopResult := t.performHop(conn, targetAddr, hop, id, probesPerHop, retries)
+		result.Hops = append(result.Hops, hopResult)
+
+		if hopResult.Success && hopResult.IP != nil && hopResult.IP.Equal(targetAddr.IP) {
+			result.Success = true
+			break
+		}
+	}
+
+	result.Duration = time.Since(start)
+	result.TotalHops = len(result.Hops)
+	result.PathCount = 1
+	result.CalculateRouteHash()
+
+	return result, nil
+}
+
+// performHop sets the TTL once, then fires probesPerHop ICMP echo requests
+// for this hop (retrying unanswered ones for up to `retries` additional
+// rounds), the same multi-probe model UDPTracer uses.
+func (t *ICMPTracer) performHop(conn *icmp.PacketConn, target *net.IPAddr, ttl, id, probesPerHop, retries int) HopResult {
+	if err := conn.IPv4PacketConn().SetTTL(ttl); err != nil {
+		return HopResult{Hop: ttl, Success: false, Error: fmt.Errorf("failed to set TTL: %w", err)}
+	}
+
+	probes := make([]ProbeResult, probesPerHop)
+	for i := range probes {
+		probes[i].Probe = i + 1
+		probes[i].Timeout = true
+	}
+
+	for round := 0; round <= retries; round++ {
+		if !t.sendAndCollectProbes(conn, target, ttl, id, probes) {
+			break
+		}
+	}
+
+	return summarizeProbes(ttl, probes)
+}
+
+// sendAndCollectProbes sends one ICMP echo request per probe still marked
+// as a timeout -- each with a seq encoding both the TTL and the probe
+// number so replies can be matched to the probe that produced them -- then
+// reads responses until every outstanding probe is matched or hopTimeout
+// elapses. It returns true if any probe is still unanswered afterwards.
+func (t *ICMPTracer) sendAndCollectProbes(conn *icmp.PacketConn, target *net.IPAddr, ttl, id int, probes []ProbeResult) bool {
+	pending := make(map[int]int) // seq -> probe index
+	sentAt := make(map[int]time.Time)
+
+	for i := range probes {
+		if !probes[i].Timeout {
+			continue
+		}
+
+		seq := ttl<<8 | probes[i].Probe
+		msg := icmp.Message{
+			Type: ipv4.ICMPTypeEcho,
+			Code: 0,
+			Body: &icmp.Echo{
+				ID:   id,
+				Seq:  seq,
+				Data: []byte("traceroute-probe"),
+			},
+		}
+		wb, err := msg.Marshal(nil)
+		if err != nil {
+			continue
+		}
+
+		probeStart := time.Now()
+		if _, err := conn.WriteTo(wb, target); err != nil {
+			continue
+		}
+
+		pending[seq] = i
+		sentAt[seq] = probeStart
+	}
+
+	if len(pending) == 0 {
+		return false
+	}
+
+	deadline := time.Now().Add(t.hopTimeout)
+	conn.SetReadDeadline(deadline)
+	buffer := make([]byte, 1500)
+
+	for len(pending) > 0 {
+		if time.Now().After(deadline) {
+			break
+		}
+
+		n, peer, err := conn.ReadFrom(buffer)
+		if err != nil {
+			break
+		}
+		if n < 8 {
+			continue
+		}
+
+		var seq int
+		switch buffer[0] {
+		case 0: // Echo Reply - the destination answered directly
+			if int(binary.BigEndian.Uint16(buffer[4:6])) != id {
+				continue
+			}
+			seq = int(binary.BigEndian.Uint16(buffer[6:8]))
+		case 11, 3: // Time Exceeded or Destination Unreachable
+			ident, err := parseEmbeddedProbe(buffer[:n], protocolICMP)
+			if err != nil || ident.srcPort != id {
+				continue
+			}
+			seq = ident.seq
+		default:
+			continue
+		}
+
+		idx, ok := pending[seq]
+		if !ok {
+			continue // reply to a different in-flight probe
+		}
+
+		var hopIP net.IP
+		if peerAddr, ok := peer.(*net.IPAddr); ok {
+			hopIP = peerAddr.IP
+		}
+
+		probes[idx].IP = hopIP
+		probes[idx].RTT = time.Since(sentAt[seq])
+		probes[idx].Success = true
+		probes[idx].Timeout = false
+
+		delete(pending, seq)
+	}
+
+	return len(pending) > 0
+}
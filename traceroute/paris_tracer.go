@@ -0,0 +1,334 @@
+package traceroute
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+// ParisTracer implements Paris-traceroute semantics: it holds a flow's
+// UDP source and destination ports constant across every hop so that
+// ECMP routers hash all of that flow's probes onto the same next-hop,
+// instead of the "different destination port per hop" approach classic
+// UDP traceroute uses (which makes ECMP reshuffling look like route
+// instability). Running parisFlows flows, each over a different fixed
+// destination port, surfaces genuine multipath routes as
+// HopResult.AlternateIPs. IPv6 targets are not supported: it resolves
+// target as an ip4 address and raw-sockets to it over ip4:udp.
+type ParisTracer struct {
+	timeout    time.Duration
+	hopTimeout time.Duration
+}
+
+// NewParisTracer creates a new Paris-traceroute instance.
+func NewParisTracer(timeout, hopTimeout time.Duration) *ParisTracer {
+	return &ParisTracer{
+		timeout:    timeout,
+		hopTimeout: hopTimeout,
+	}
+}
+
+// Trace runs parisFlows Paris-traceroute passes against target, each with
+// a different fixed destination port, and merges their per-hop IP sets
+// into a single multipath-aware result. ctx bounds the whole trace in
+// addition to t.timeout -- whichever is shorter wins -- so a caller's
+// deadline or cancellation actually stops in-flight work instead of
+// letting every remaining flow and hop run to completion.
+func (t *ParisTracer) Trace(ctx context.Context, target string, firstHop, maxHops, startPort, probesPerHop, retries, parisFlows int) (*TracerouteResult, error) {
+	start := time.Now()
+
+	if parisFlows < 1 {
+		parisFlows = 1
+	}
+
+	targetAddr, err := net.ResolveIPAddr("ip4", target)
+	if err != nil {
+		return &TracerouteResult{
+			Target:   target,
+			Success:  false,
+			Duration: time.Since(start),
+			Error:    fmt.Errorf("failed to resolve target: %w", err),
+		}, err
+	}
+
+	srcIP, err := localIPv4For(targetAddr.IP)
+	if err != nil {
+		return &TracerouteResult{
+			Target:   target,
+			Success:  false,
+			Duration: time.Since(start),
+			Error:    fmt.Errorf("failed to determine local source IP: %w", err),
+		}, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+
+	flows := make([]*TracerouteResult, 0, parisFlows)
+	for flow := 0; flow < parisFlows; flow++ {
+		select {
+		case <-ctx.Done():
+		default:
+			flows = append(flows, t.traceFlow(ctx, srcIP, targetAddr.IP, firstHop, maxHops, startPort+flow, probesPerHop, retries))
+		}
+	}
+
+	result := mergeParisFlows(target, flows)
+	result.Duration = time.Since(start)
+
+	return result, nil
+}
+
+// traceFlow runs one Paris-traceroute pass over a single fixed
+// source/destination port pair, stopping early if ctx is done.
+func (t *ParisTracer) traceFlow(ctx context.Context, srcIP, dstIP net.IP, firstHop, maxHops, destPort, probesPerHop, retries int) *TracerouteResult {
+	result := &TracerouteResult{
+		Target:     dstIP.String(),
+		TargetName: dstIP.String(),
+		Hops:       make([]HopResult, 0, maxHops),
+	}
+
+	rawConn, err := net.ListenPacket("ip4:udp", "0.0.0.0")
+	if err != nil {
+		result.Error = fmt.Errorf("failed to open raw UDP socket: %w", err)
+		return result
+	}
+	defer rawConn.Close()
+
+	packetConn, err := ipv4.NewRawConn(rawConn)
+	if err != nil {
+		result.Error = fmt.Errorf("failed to create raw IPv4 connection: %w", err)
+		return result
+	}
+
+	icmpConn, err := icmp.ListenPacket("ip4:icmp", "0.0.0.0")
+	if err != nil {
+		result.Error = fmt.Errorf("failed to create ICMP listener: %w", err)
+		return result
+	}
+	defer icmpConn.Close()
+
+	srcPort := 40000 + rand.Intn(10000)
+
+hopLoop:
+	for hop := firstHop; hop <= maxHops; hop++ {
+		select {
+		case <-ctx.Done():
+			result.Error = fmt.Errorf("traceroute timeout")
+			break hopLoop
+		default:
+		}
+
+		hopResult := t.performHop(packetConn, icmpConn, srcIP, dstIP, hop, srcPort, destPort, probesPerHop, retries)
+		result.Hops = append(result.Hops, hopResult)
+
+		if hopResult.Success && hopResult.IP != nil && hopResult.IP.Equal(dstIP) {
+			result.Success = true
+			break
+		}
+	}
+
+	result.TotalHops = len(result.Hops)
+
+	return result
+}
+
+// performHop fires probesPerHop Paris-mode UDP probes for a single TTL,
+// keyed by checksum identifier rather than port, and retries unanswered
+// ones for up to `retries` additional rounds.
+func (t *ParisTracer) performHop(packetConn *ipv4.RawConn, icmpConn *icmp.PacketConn, srcIP, dstIP net.IP, ttl, srcPort, destPort, probesPerHop, retries int) HopResult {
+	probes := make([]ProbeResult, probesPerHop)
+	for i := range probes {
+		probes[i].Probe = i + 1
+		probes[i].Timeout = true
+	}
+
+	for round := 0; round <= retries; round++ {
+		if !t.sendAndCollectProbes(packetConn, icmpConn, srcIP, dstIP, ttl, srcPort, destPort, probes) {
+			break
+		}
+	}
+
+	return summarizeProbes(ttl, probes)
+}
+
+// sendAndCollectProbes sends one TTL-limited, checksum-tagged UDP probe
+// per probe still marked as a timeout, then reads ICMP responses until
+// every outstanding probe is matched or hopTimeout elapses.
+func (t *ParisTracer) sendAndCollectProbes(packetConn *ipv4.RawConn, icmpConn *icmp.PacketConn, srcIP, dstIP net.IP, ttl, srcPort, destPort int, probes []ProbeResult) bool {
+	pending := make(map[uint16]int) // checksum id -> probe index
+	sentAt := make(map[uint16]time.Time)
+
+	for i := range probes {
+		if !probes[i].Timeout {
+			continue
+		}
+
+		id := uint16(ttl)<<8 | uint16(probes[i].Probe)
+		segment := buildParisUDP(srcIP, dstIP, srcPort, destPort, id, []byte("traceroute-probe"))
+
+		header := &ipv4.Header{
+			Version:  ipv4.Version,
+			Len:      ipv4.HeaderLen,
+			TotalLen: ipv4.HeaderLen + len(segment),
+			TTL:      ttl,
+			Protocol: protocolUDP,
+			Src:      srcIP,
+			Dst:      dstIP,
+		}
+
+		probeStart := time.Now()
+		if err := packetConn.WriteTo(header, segment, nil); err != nil {
+			continue
+		}
+
+		pending[id] = i
+		sentAt[id] = probeStart
+	}
+
+	if len(pending) == 0 {
+		return false
+	}
+
+	deadline := time.Now().Add(t.hopTimeout)
+	buffer := make([]byte, 1500)
+
+	for len(pending) > 0 {
+		if time.Now().After(deadline) {
+			break
+		}
+		icmpConn.SetReadDeadline(deadline)
+
+		n, peer, err := icmpConn.ReadFrom(buffer)
+		if err != nil {
+			break
+		}
+		if n < 8 || (buffer[0] != 11 && buffer[0] != 3) { // Time Exceeded or Destination Unreachable
+			continue
+		}
+
+		checksum, err := parseEmbeddedUDPChecksum(buffer[:n])
+		if err != nil {
+			continue
+		}
+		idx, ok := pending[checksum]
+		if !ok {
+			continue // reply to a different in-flight probe
+		}
+
+		var hopIP net.IP
+		if peerAddr, ok := peer.(*net.IPAddr); ok {
+			hopIP = peerAddr.IP
+		}
+
+		probes[idx].IP = hopIP
+		probes[idx].RTT = time.Since(sentAt[checksum])
+		probes[idx].Success = true
+		probes[idx].Timeout = false
+
+		delete(pending, checksum)
+	}
+
+	return len(pending) > 0
+}
+
+// mergeParisFlows combines the results of running several Paris-traceroute
+// flows into a single TracerouteResult: the first flow's per-hop response
+// becomes the primary hop, and any other flow's differing IP at that hop
+// is recorded as an alternate path.
+func mergeParisFlows(target string, flows []*TracerouteResult) *TracerouteResult {
+	merged := &TracerouteResult{Target: target, TargetName: target}
+	if len(flows) == 0 {
+		return merged
+	}
+
+	maxHops := 0
+	for _, flow := range flows {
+		if len(flow.Hops) > maxHops {
+			maxHops = len(flow.Hops)
+		}
+	}
+
+	merged.Hops = make([]HopResult, 0, maxHops)
+	for i := 0; i < maxHops; i++ {
+		var primary HopResult
+		havePrimary := false
+		seen := make(map[string]net.IP)
+
+		for _, flow := range flows {
+			if i >= len(flow.Hops) {
+				continue
+			}
+			hop := flow.Hops[i]
+			if !havePrimary {
+				primary = hop
+				havePrimary = true
+			}
+			if hop.Success && hop.IP != nil {
+				seen[hop.IP.String()] = hop.IP
+			}
+		}
+
+		merged.Hops = append(merged.Hops, buildMultipathHop(primary, seen))
+	}
+
+	for _, flow := range flows {
+		if flow.Success {
+			merged.Success = true
+		}
+	}
+
+	merged.TotalHops = len(merged.Hops)
+	merged.PathCount = countDistinctPaths(flows)
+	merged.CalculateRouteHash()
+
+	return merged
+}
+
+// buildMultipathHop attaches any IP seen at this hop other than the
+// primary flow's response as an AlternateIPs entry, sorted for stability.
+func buildMultipathHop(primary HopResult, seen map[string]net.IP) HopResult {
+	hop := primary
+	hop.AlternateIPs = nil
+
+	keys := make([]string, 0, len(seen))
+	for k := range seen {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		ip := seen[k]
+		if hop.Success && hop.IP != nil && ip.Equal(hop.IP) {
+			continue
+		}
+		hop.AlternateIPs = append(hop.AlternateIPs, ip)
+	}
+
+	return hop
+}
+
+// countDistinctPaths counts how many genuinely different hop-IP sequences
+// were observed across flows, so traceroute_path_count reflects real
+// multipath routes rather than per-probe noise.
+func countDistinctPaths(flows []*TracerouteResult) int {
+	seen := make(map[string]struct{})
+	for _, flow := range flows {
+		var b strings.Builder
+		for _, hop := range flow.Hops {
+			if hop.Success && hop.IP != nil {
+				b.WriteString(hop.IP.String())
+			}
+			b.WriteByte('|')
+		}
+		seen[b.String()] = struct{}{}
+	}
+	return len(seen)
+}
@@ -0,0 +1,104 @@
+package traceroute
+
+import (
+	"encoding/binary"
+	"net"
+)
+
+// onesComplementSum adds up data as big-endian 16-bit words using ones'
+// complement arithmetic, the building block of the IPv4/UDP/TCP checksum.
+func onesComplementSum(data []byte) uint32 {
+	var sum uint32
+	for i := 0; i+1 < len(data); i += 2 {
+		sum += uint32(data[i])<<8 | uint32(data[i+1])
+	}
+	if len(data)%2 == 1 {
+		sum += uint32(data[len(data)-1]) << 8
+	}
+	return sum
+}
+
+// foldChecksum folds the carries of a ones' complement sum back into 16 bits.
+func foldChecksum(sum uint32) uint16 {
+	for sum>>16 != 0 {
+		sum = (sum & 0xffff) + (sum >> 16)
+	}
+	return uint16(sum)
+}
+
+// onesComplementAdd16 adds two 16-bit values under ones' complement
+// arithmetic (end-around carry).
+func onesComplementAdd16(a, b uint16) uint16 {
+	return foldChecksum(uint32(a) + uint32(b))
+}
+
+// onesComplementSub16 subtracts b from a under ones' complement
+// arithmetic; used to solve for the padding that makes a checksum equal
+// to a chosen value.
+func onesComplementSub16(a, b uint16) uint16 {
+	return onesComplementAdd16(a, complement16(b))
+}
+
+// complement16 returns the ones' complement (bitwise NOT) of a 16-bit value.
+func complement16(x uint16) uint16 {
+	return x ^ 0xffff
+}
+
+// ipv4PseudoHeader builds the pseudo-header IPv4 checksums are computed
+// over: source/destination address, zero, protocol, and segment length.
+func ipv4PseudoHeader(srcIP, dstIP net.IP, proto byte, length int) []byte {
+	header := make([]byte, 12)
+	copy(header[0:4], srcIP.To4())
+	copy(header[4:8], dstIP.To4())
+	header[9] = proto
+	binary.BigEndian.PutUint16(header[10:12], uint16(length))
+	return header
+}
+
+// buildParisUDP constructs a UDP datagram from srcIP:srcPort to
+// dstIP:dstPort whose valid checksum happens to equal id. Because the
+// checksum field of the original datagram is echoed back verbatim inside
+// any ICMP Time Exceeded / Destination Unreachable it provokes, this
+// gives each probe a correlation identifier without varying the 5-tuple
+// an ECMP router hashes on -- the classic Paris-traceroute "checksum ID"
+// trick. We solve for the two padding bytes directly via the linearity
+// of the ones' complement checksum rather than searching for them.
+func buildParisUDP(srcIP, dstIP net.IP, srcPort, dstPort int, id uint16, payload []byte) []byte {
+	body := make([]byte, len(payload)+2) // last 2 bytes are the tunable pad
+	copy(body, payload)
+
+	udpLen := 8 + len(body)
+	packet := make([]byte, udpLen)
+	binary.BigEndian.PutUint16(packet[0:2], uint16(srcPort))
+	binary.BigEndian.PutUint16(packet[2:4], uint16(dstPort))
+	binary.BigEndian.PutUint16(packet[4:6], uint16(udpLen))
+	copy(packet[8:], body)
+	// Checksum field (packet[6:8]) and the pad bytes are both still zero.
+
+	pseudoHeader := ipv4PseudoHeader(srcIP, dstIP, protocolUDP, udpLen)
+	base := foldChecksum(onesComplementSum(pseudoHeader) + onesComplementSum(packet))
+
+	pad := onesComplementSub16(complement16(id), base)
+	binary.BigEndian.PutUint16(packet[udpLen-2:udpLen], pad)
+
+	checksum := complement16(foldChecksum(onesComplementSum(pseudoHeader) + onesComplementSum(packet)))
+	if checksum == 0 {
+		checksum = 0xffff // 0 means "no checksum" in UDP over IPv4
+	}
+	binary.BigEndian.PutUint16(packet[6:8], checksum)
+
+	return packet
+}
+
+// localIPv4For returns the local address the kernel would route through
+// to reach dst, without sending any packets (the "connected UDP socket"
+// trick). We need this because raw IP_HDRINCL writes require us to fill
+// in the source address ourselves.
+func localIPv4For(dst net.IP) (net.IP, error) {
+	conn, err := net.Dial("udp4", net.JoinHostPort(dst.String(), "80"))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP, nil
+}
@@ -0,0 +1,24 @@
+package traceroute
+
+import (
+	"context"
+	"log"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/ys3669/traceroute-exporter/config"
+)
+
+// Prober runs a single traceroute against target using the given module
+// configuration and records the resulting metrics into registry. It
+// returns true if the probe reached its destination successfully.
+type Prober interface {
+	Probe(ctx context.Context, target string, module config.Module, registry *prometheus.Registry, logger *log.Logger) bool
+}
+
+// Probers maps a module's "prober" name to its implementation, mirroring
+// blackbox_exporter's prober registry.
+var Probers = map[string]Prober{
+	"udp":  UDPProber{},
+	"icmp": ICMPProber{},
+	"tcp":  TCPProber{},
+}
@@ -1,21 +1,134 @@
 package traceroute
 
 import (
+	"math"
 	"net"
+	"sort"
 	"time"
 )
 
-// HopResult represents the result of a single hop
-type HopResult struct {
-	Hop     int           `json:"hop"`
+// ProbeResult represents a single probe sent for one hop. Classic
+// traceroute sends several probes per TTL to characterize jitter;
+// HopResult.Probes captures each individual attempt.
+type ProbeResult struct {
+	Probe   int           `json:"probe"`
 	IP      net.IP        `json:"ip,omitempty"`
 	RTT     time.Duration `json:"rtt"`
 	Success bool          `json:"success"`
 	Timeout bool          `json:"timeout"`
-	Error   error         `json:"error,omitempty"`
 }
 
-// TracerouteResult represents the complete traceroute result
+// HopResult represents the result of a single hop. IP/RTT/Success/Timeout
+// summarize the hop's first successful probe for callers that don't care
+// about per-probe detail; Probes carries the full set when a tracer sends
+// more than one probe per hop. AlternateIPs holds any additional hop IPs
+// discovered by Paris-traceroute multipath probing (see ParisTracer). ASN,
+// ASOrg, Country and City are populated by EnrichHops when a GeoIP/ASN
+// enricher is configured; they're left zero-valued otherwise.
+type HopResult struct {
+	Hop          int           `json:"hop"`
+	IP           net.IP        `json:"ip,omitempty"`
+	RTT          time.Duration `json:"rtt"`
+	Success      bool          `json:"success"`
+	Timeout      bool          `json:"timeout"`
+	Error        error         `json:"error,omitempty"`
+	Probes       []ProbeResult `json:"probes,omitempty"`
+	AlternateIPs []net.IP      `json:"alternate_ips,omitempty"`
+	ASN          uint32        `json:"asn,omitempty"`
+	ASOrg        string        `json:"as_org,omitempty"`
+	Country      string        `json:"country,omitempty"`
+	City         string        `json:"city,omitempty"`
+}
+
+// summarizeProbes reduces a hop's individual probe results to the classic
+// single-value HopResult fields (the first successful probe's IP/RTT),
+// for backward compatibility with route-hash calculation and callers that
+// don't care about per-probe detail, while Probes keeps the full set.
+func summarizeProbes(ttl int, probes []ProbeResult) HopResult {
+	hop := HopResult{Hop: ttl, Probes: probes, Timeout: true}
+
+	for _, p := range probes {
+		if p.Success {
+			hop.IP = p.IP
+			hop.RTT = p.RTT
+			hop.Success = true
+			hop.Timeout = false
+			break
+		}
+	}
+
+	return hop
+}
+
+// allIPs returns every distinct IP seen at this hop (the primary response
+// plus any Paris-traceroute alternates), sorted so that CalculateRouteHash
+// is stable regardless of probe or flow order.
+func (h HopResult) allIPs() []net.IP {
+	seen := make(map[string]net.IP)
+	if h.Success && h.IP != nil {
+		seen[h.IP.String()] = h.IP
+	}
+	for _, ip := range h.AlternateIPs {
+		seen[ip.String()] = ip
+	}
+	if len(seen) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(seen))
+	for k := range seen {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	ips := make([]net.IP, len(keys))
+	for i, k := range keys {
+		ips[i] = seen[k]
+	}
+	return ips
+}
+
+// RTTStats returns the min/avg/max/stddev RTT across the hop's successful
+// probes, for exposing per-hop jitter. All four are zero if no probe
+// succeeded.
+func (h HopResult) RTTStats() (min, avg, max, stddev time.Duration) {
+	var rtts []time.Duration
+	for _, p := range h.Probes {
+		if p.Success {
+			rtts = append(rtts, p.RTT)
+		}
+	}
+	if len(rtts) == 0 {
+		return 0, 0, 0, 0
+	}
+
+	min, max = rtts[0], rtts[0]
+	var sum time.Duration
+	for _, r := range rtts {
+		if r < min {
+			min = r
+		}
+		if r > max {
+			max = r
+		}
+		sum += r
+	}
+	avg = sum / time.Duration(len(rtts))
+
+	var varianceSum float64
+	for _, r := range rtts {
+		d := float64(r - avg)
+		varianceSum += d * d
+	}
+	stddev = time.Duration(math.Sqrt(varianceSum / float64(len(rtts))))
+
+	return min, avg, max, stddev
+}
+
+// TracerouteResult represents the complete traceroute result. PathCount
+// is the number of distinct end-to-end paths observed: 1 for a normal
+// single-flow trace, or >1 when Paris-traceroute multipath probing finds
+// genuine ECMP route diversity.
 type TracerouteResult struct {
 	Target     string        `json:"target"`
 	TargetName string        `json:"target_name"`
@@ -24,6 +137,7 @@ type TracerouteResult struct {
 	Success    bool          `json:"success"`
 	Duration   time.Duration `json:"duration"`
 	RouteHash  uint32        `json:"route_hash"`
+	PathCount  int           `json:"path_count"`
 	Error      error         `json:"error,omitempty"`
 }
 
@@ -43,15 +157,19 @@ func (tr *TracerouteResult) GetSuccessfulHops() int {
 	return count
 }
 
-// CalculateRouteHash calculates a hash of the route for change detection
+// CalculateRouteHash calculates a hash of the route for change detection.
+// Every distinct IP seen at a hop (the primary response plus any
+// Paris-traceroute AlternateIPs) is folded in sorted order, so ECMP
+// reshuffling among the same set of next-hops doesn't look like a
+// topology change.
 func (tr *TracerouteResult) CalculateRouteHash() {
-	// Simple hash calculation based on hop IPs
 	var hash uint32 = 0
 	for _, hop := range tr.Hops {
-		if hop.Success && hop.IP != nil {
-			for _, b := range hop.IP {
+		for _, ip := range hop.allIPs() {
+			for _, b := range ip {
 				hash = hash*31 + uint32(b)
 			}
+			hash = hash*31 + 1 // separator between IPs within a hop
 		}
 	}
 	tr.RouteHash = hash
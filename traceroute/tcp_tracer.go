@@ -0,0 +1,315 @@
+package traceroute
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+// TCPTracer implements TCP SYN-based traceroute by sending TTL-limited
+// SYN segments to a fixed destination port. This traces paths through
+// firewalls that drop the UDP/ICMP probes the other tracers rely on.
+// IPv6 targets are not supported: it opens a raw ip4:tcp socket, not ip6:tcp.
+type TCPTracer struct {
+	timeout    time.Duration
+	hopTimeout time.Duration
+	port       int
+}
+
+// NewTCPTracer creates a new TCP SYN traceroute instance targeting port
+// (443 if zero).
+func NewTCPTracer(timeout, hopTimeout time.Duration, port int) *TCPTracer {
+	if port == 0 {
+		port = 443
+	}
+	return &TCPTracer{
+		timeout:    timeout,
+		hopTimeout: hopTimeout,
+		port:       port,
+	}
+}
+
+// Trace performs TCP SYN-based traceroute to the target. probesPerHop
+// probes are fired per TTL, with up to retries additional rounds for
+// probes that don't get a response. ctx bounds the whole trace in
+// addition to t.timeout -- whichever is shorter wins -- so a caller's
+// deadline or cancellation actually stops in-flight work.
+func (t *TCPTracer) Trace(ctx context.Context, target string, firstHop, maxHops, probesPerHop, retries int) (*TracerouteResult, error) {
+	start := time.Now()
+
+	targetAddr, err := net.ResolveIPAddr("ip4", target)
+	if err != nil {
+		return &TracerouteResult{
+			Target:   target,
+			Success:  false,
+			Duration: time.Since(start),
+			Error:    fmt.Errorf("failed to resolve target: %w", err),
+		}, err
+	}
+
+	srcIP, err := localIPv4For(targetAddr.IP)
+	if err != nil {
+		return &TracerouteResult{
+			Target:   target,
+			Success:  false,
+			Duration: time.Since(start),
+			Error:    fmt.Errorf("failed to determine local source IP: %w", err),
+		}, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+
+	result := &TracerouteResult{
+		Target:     target,
+		TargetName: target,
+		Hops:       make([]HopResult, 0, maxHops),
+		Success:    false,
+		Duration:   0,
+	}
+
+	rawConn, err := net.ListenPacket("ip4:tcp", "0.0.0.0")
+	if err != nil {
+		return result, fmt.Errorf("failed to open raw TCP socket: %w", err)
+	}
+	defer rawConn.Close()
+
+	packetConn, err := ipv4.NewRawConn(rawConn)
+	if err != nil {
+		return result, fmt.Errorf("failed to create raw IPv4 connection: %w", err)
+	}
+
+	icmpConn, err := icmp.ListenPacket("ip4:icmp", "0.0.0.0")
+	if err != nil {
+		return result, fmt.Errorf("failed to create ICMP listener: %w", err)
+	}
+	defer icmpConn.Close()
+
+	basePort := 20000 + rand.Intn(10000)
+
+hopLoop:
+	for hop := firstHop; hop <= maxHops; hop++ {
+		select {
+		case <-ctx.Done():
+			result.Error = fmt.Errorf("traceroute timeout")
+			break hopLoop
+		default:
+		}
+
+		hopResult := t.performHop(packetConn, icmpConn, srcIP, targetAddr, hop, basePort+(hop-firstHop)*probesPerHop, probesPerHop, retries)
+		result.Hops = append(result.Hops, hopResult)
+
+		if hopResult.Success && hopResult.IP != nil && hopResult.IP.Equal(targetAddr.IP) {
+			result.Success = true
+			break
+		}
+	}
+
+	result.Duration = time.Since(start)
+	result.TotalHops = len(result.Hops)
+	result.PathCount = 1
+	result.CalculateRouteHash()
+
+	return result, nil
+}
+
+// tcpProbeReply is what readTCPMatches/readICMPMatches report on the
+// shared matches channel: a reply to one outstanding probe, identified by
+// the source port that probe was sent from (the ICMP Time Exceeded an
+// intermediate hop sends, or a SYN-ACK/RST from the target answering the
+// SYN directly -- which means we've reached the destination, not just an
+// intermediate hop -- the entire point of probing via TCP through
+// firewalls that drop ICMP/UDP).
+type tcpProbeReply struct {
+	srcPort int
+	ip      net.IP
+}
+
+// performHop fires probesPerHop TTL-limited TCP SYN segments for this
+// hop, each from a distinct source port, then drains TCP/ICMP responses
+// until every probe is matched or hopTimeout elapses. Any probes still
+// unanswered afterwards are retried for up to `retries` additional
+// rounds -- the same multi-probe model UDPTracer uses.
+func (t *TCPTracer) performHop(packetConn *ipv4.RawConn, icmpConn *icmp.PacketConn, srcIP net.IP, target *net.IPAddr, ttl, basePort, probesPerHop, retries int) HopResult {
+	probes := make([]ProbeResult, probesPerHop)
+	for i := range probes {
+		probes[i].Probe = i + 1
+		probes[i].Timeout = true
+	}
+
+	for round := 0; round <= retries; round++ {
+		if !t.sendAndCollectProbes(packetConn, icmpConn, srcIP, target, ttl, basePort, probes) {
+			break
+		}
+	}
+
+	return summarizeProbes(ttl, probes)
+}
+
+// sendAndCollectProbes sends one TCP SYN per probe still marked as a
+// timeout -- each from basePort+probe index, with a sequence number
+// encoding the TTL and probe number -- then reads TCP and ICMP responses
+// concurrently until every outstanding probe is matched or hopTimeout
+// elapses. It returns true if any probe is still unanswered afterwards.
+func (t *TCPTracer) sendAndCollectProbes(packetConn *ipv4.RawConn, icmpConn *icmp.PacketConn, srcIP net.IP, target *net.IPAddr, ttl, basePort int, probes []ProbeResult) bool {
+	pending := make(map[int]int)      // src port -> probe index
+	sentAt := make(map[int]time.Time) // src port -> send time
+	seqs := make(map[int]uint32)      // src port -> seq sent (read-only once readers start)
+
+	for i := range probes {
+		if !probes[i].Timeout {
+			continue
+		}
+
+		srcPort := basePort + i
+		seq := uint32(ttl)<<8 | uint32(probes[i].Probe)
+		synSegment := buildTCPSYN(srcIP, target.IP, srcPort, t.port, seq)
+
+		header := &ipv4.Header{
+			Version:  ipv4.Version,
+			Len:      ipv4.HeaderLen,
+			TotalLen: ipv4.HeaderLen + len(synSegment),
+			TTL:      ttl,
+			Protocol: protocolTCP,
+			Src:      srcIP,
+			Dst:      target.IP,
+		}
+
+		probeStart := time.Now()
+		if err := packetConn.WriteTo(header, synSegment, nil); err != nil {
+			continue
+		}
+
+		pending[srcPort] = i
+		sentAt[srcPort] = probeStart
+		seqs[srcPort] = seq
+	}
+
+	if len(pending) == 0 {
+		return false
+	}
+
+	deadline := time.Now().Add(t.hopTimeout)
+	icmpConn.SetReadDeadline(deadline)
+	packetConn.SetReadDeadline(deadline)
+
+	matches := make(chan tcpProbeReply, len(pending)*2)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() { defer wg.Done(); t.readTCPMatches(packetConn, target.IP, seqs, matches) }()
+	go func() { defer wg.Done(); t.readICMPMatches(icmpConn, matches) }()
+	go func() { wg.Wait(); close(matches) }()
+
+	for m := range matches {
+		idx, ok := pending[m.srcPort]
+		if !ok {
+			continue // reply to a different in-flight probe
+		}
+
+		probes[idx].IP = m.ip
+		probes[idx].RTT = time.Since(sentAt[m.srcPort])
+		probes[idx].Success = true
+		probes[idx].Timeout = false
+
+		delete(pending, m.srcPort)
+		if len(pending) == 0 {
+			// Every probe is answered: unblock both readers now instead
+			// of waiting out the rest of hopTimeout.
+			now := time.Now()
+			icmpConn.SetReadDeadline(now)
+			packetConn.SetReadDeadline(now)
+		}
+	}
+
+	return len(pending) > 0
+}
+
+// readTCPMatches reads raw TCP segments until its deadline expires,
+// reporting any that acknowledge one of our outstanding SYNs (a SYN-ACK
+// or RST from target, addressed to a port in seqs with ack == seq+1) --
+// i.e. the destination answering directly. seqs is never written to once
+// the readers are started, so concurrent reads from it are safe.
+func (t *TCPTracer) readTCPMatches(packetConn *ipv4.RawConn, target net.IP, seqs map[int]uint32, matches chan<- tcpProbeReply) {
+	buffer := make([]byte, 1500)
+	for {
+		header, payload, _, err := packetConn.ReadFrom(buffer)
+		if err != nil {
+			return
+		}
+		if header.Src == nil || !header.Src.Equal(target) || len(payload) < 14 {
+			continue
+		}
+
+		dstPort := int(binary.BigEndian.Uint16(payload[2:4]))
+		ack := binary.BigEndian.Uint32(payload[8:12])
+		flags := payload[13]
+		const synAckFlags = 0x12 // SYN+ACK
+		const rstFlag = 0x04
+
+		seq, ok := seqs[dstPort]
+		if !ok || ack != seq+1 {
+			continue
+		}
+		if flags&rstFlag != 0 || flags&synAckFlags == synAckFlags {
+			matches <- tcpProbeReply{srcPort: dstPort, ip: target}
+		}
+	}
+}
+
+// readICMPMatches reads from the ICMP listener until its deadline
+// expires, reporting every Time Exceeded / Destination Unreachable that
+// embeds one of our probes (identified by source port).
+func (t *TCPTracer) readICMPMatches(icmpConn *icmp.PacketConn, matches chan<- tcpProbeReply) {
+	buffer := make([]byte, 1500)
+	for {
+		n, peer, err := icmpConn.ReadFrom(buffer)
+		if err != nil {
+			return
+		}
+		if n < 8 || (buffer[0] != 11 && buffer[0] != 3) { // Time Exceeded or Destination Unreachable
+			continue
+		}
+
+		ident, err := parseEmbeddedProbe(buffer[:n], protocolTCP)
+		if err != nil || ident.seq != t.port {
+			continue
+		}
+
+		var hopIP net.IP
+		if peerAddr, ok := peer.(*net.IPAddr); ok {
+			hopIP = peerAddr.IP
+		}
+		matches <- tcpProbeReply{srcPort: ident.srcPort, ip: hopIP}
+	}
+}
+
+// buildTCPSYN constructs a minimal TCP SYN segment (no options) with the
+// given source/destination ports and sequence number, with a correct
+// pseudo-header checksum. This matters even though intermediate routers
+// generating our Time Exceeded replies don't validate it: the destination
+// does, and a real TCP/IP stack silently drops a segment with an invalid
+// checksum, so without one readTCPMatches would never see a SYN-ACK/RST
+// from a real target.
+func buildTCPSYN(srcIP, dstIP net.IP, srcPort, dstPort int, seq uint32) []byte {
+	buf := make([]byte, 20)
+	binary.BigEndian.PutUint16(buf[0:2], uint16(srcPort))
+	binary.BigEndian.PutUint16(buf[2:4], uint16(dstPort))
+	binary.BigEndian.PutUint32(buf[4:8], seq)
+	binary.BigEndian.PutUint32(buf[8:12], 0) // ack number
+	buf[12] = 5 << 4                         // data offset: 5 words, no options
+	buf[13] = 0x02                           // SYN flag
+	binary.BigEndian.PutUint16(buf[14:16], 65535)
+
+	pseudoHeader := ipv4PseudoHeader(srcIP, dstIP, protocolTCP, len(buf))
+	checksum := complement16(foldChecksum(onesComplementSum(pseudoHeader) + onesComplementSum(buf)))
+	binary.BigEndian.PutUint16(buf[16:18], checksum)
+
+	return buf
+}
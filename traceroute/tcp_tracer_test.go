@@ -0,0 +1,48 @@
+package traceroute
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+)
+
+// TestBuildTCPSYNChecksum guards against the checksum field being left
+// zero (as it once was): a segment with a correct TCP checksum sums to
+// the all-ones value (0xffff) when the pseudo-header, segment, and its
+// own checksum field are all summed together under ones' complement
+// arithmetic -- the standard way to validate an Internet checksum without
+// recomputing it the same way production code does.
+func TestBuildTCPSYNChecksum(t *testing.T) {
+	srcIP := net.ParseIP("192.0.2.1")
+	dstIP := net.ParseIP("192.0.2.2")
+
+	segment := buildTCPSYN(srcIP, dstIP, 12345, 443, 0xdeadbeef)
+
+	checksum := binary.BigEndian.Uint16(segment[16:18])
+	if checksum == 0 {
+		t.Fatalf("buildTCPSYN left the checksum field zero")
+	}
+
+	pseudoHeader := make([]byte, 12)
+	copy(pseudoHeader[0:4], srcIP.To4())
+	copy(pseudoHeader[4:8], dstIP.To4())
+	pseudoHeader[9] = protocolTCP
+	binary.BigEndian.PutUint16(pseudoHeader[10:12], uint16(len(segment)))
+
+	var sum uint32
+	for _, buf := range [][]byte{pseudoHeader, segment} {
+		for i := 0; i+1 < len(buf); i += 2 {
+			sum += uint32(buf[i])<<8 | uint32(buf[i+1])
+		}
+		if len(buf)%2 == 1 {
+			sum += uint32(buf[len(buf)-1]) << 8
+		}
+	}
+	for sum>>16 != 0 {
+		sum = (sum & 0xffff) + (sum >> 16)
+	}
+
+	if sum != 0xffff {
+		t.Errorf("checksum does not validate: pseudo-header+segment ones'-complement sum = %#x, want 0xffff", sum)
+	}
+}
@@ -0,0 +1,43 @@
+package traceroute
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/ys3669/traceroute-exporter/config"
+)
+
+// UDPProber runs a UDP-based traceroute module. It implements Prober.
+type UDPProber struct{}
+
+// Probe implements Prober.
+func (UDPProber) Probe(ctx context.Context, target string, module config.Module, registry *prometheus.Registry, logger *log.Logger) bool {
+	var result *TracerouteResult
+	var err error
+
+	start := time.Now()
+	if module.ParisFlows > 1 {
+		tracer := NewParisTracer(module.Timeout, module.HopTimeout)
+		result, err = tracer.Trace(ctx, target, module.FirstHop, module.MaxHops, module.StartPort, module.ProbesPerHop, module.Retries, module.ParisFlows)
+	} else {
+		tracer := NewUDPTracer(module.Timeout, module.HopTimeout)
+		result, err = tracer.Trace(ctx, target, module.FirstHop, module.MaxHops, module.StartPort, module.ProbesPerHop, module.Retries)
+	}
+
+	if err != nil {
+		logger.Printf("UDP traceroute to %s failed: %v", target, err)
+	}
+	if result == nil {
+		return false
+	}
+	result.TargetName = target
+	EnrichHops(result)
+
+	collector := NewTracerouteCollector()
+	collector.Update(result, time.Since(start))
+	registry.MustRegister(collector)
+
+	return result.Success
+}
@@ -8,9 +8,12 @@ import (
 	"time"
 
 	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
 )
 
-// UDPTracer implements UDP-based traceroute
+// UDPTracer implements UDP-based traceroute. IPv6 targets are not
+// supported: it resolves target over udp4 and sets TTL via
+// golang.org/x/net/ipv4, not ipv6.PacketConn.HopLimit.
 type UDPTracer struct {
 	timeout    time.Duration
 	hopTimeout time.Duration
@@ -24,8 +27,15 @@ func NewUDPTracer(timeout, hopTimeout time.Duration) *UDPTracer {
 	}
 }
 
-// Trace performs UDP-based traceroute to the target
-func (t *UDPTracer) Trace(target string, maxHops int, startPort int) (*TracerouteResult, error) {
+// Trace performs UDP-based traceroute to the target, starting at firstHop
+// (1 for a normal traceroute) and walking up to maxHops. probesPerHop
+// probes are fired per TTL, with up to retries additional rounds for
+// probes that don't get a response. ctx bounds the whole trace in
+// addition to t.timeout -- whichever is shorter wins -- so a caller's
+// deadline or cancellation (e.g. an HTTP client disconnecting from
+// /probe) actually stops in-flight work instead of running to
+// completion regardless.
+func (t *UDPTracer) Trace(ctx context.Context, target string, firstHop int, maxHops int, startPort int, probesPerHop int, retries int) (*TracerouteResult, error) {
 	start := time.Now()
 
 	// Resolve target address
@@ -39,7 +49,7 @@ func (t *UDPTracer) Trace(target string, maxHops int, startPort int) (*Tracerout
 		}, err
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), t.timeout)
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
 	defer cancel()
 
 	result := &TracerouteResult{
@@ -58,15 +68,16 @@ func (t *UDPTracer) Trace(target string, maxHops int, startPort int) (*Tracerout
 	defer icmpConn.Close()
 
 	// Perform traceroute hop by hop
-	for hop := 1; hop <= maxHops; hop++ {
+hopLoop:
+	for hop := firstHop; hop <= maxHops; hop++ {
 		select {
 		case <-ctx.Done():
 			result.Error = fmt.Errorf("traceroute timeout")
-			break
+			break hopLoop
 		default:
 		}
 
-		hopResult := t.performHop(ctx, targetAddr, hop, startPort+hop-1, icmpConn)
+		hopResult := t.performHop(targetAddr, hop, startPort+hop-firstHop, icmpConn, probesPerHop, retries)
 		result.Hops = append(result.Hops, hopResult)
 
 		// Check if we reached the destination
@@ -86,120 +97,124 @@ func (t *UDPTracer) Trace(target string, maxHops int, startPort int) (*Tracerout
 
 	result.Duration = time.Since(start)
 	result.TotalHops = len(result.Hops)
+	result.PathCount = 1
 	result.CalculateRouteHash()
 
 	return result, nil
 }
 
-// performHop performs a single hop of the traceroute
-func (t *UDPTracer) performHop(ctx context.Context, target *net.UDPAddr, ttl int, port int, icmpConn *icmp.PacketConn) HopResult {
-	start := time.Now()
+// performHop fires probesPerHop UDP probes for a single TTL, each from a
+// distinct source port, then drains ICMP responses until every probe is
+// matched or hopTimeout elapses. Any probes still unanswered afterwards
+// are retried for up to `retries` additional rounds.
+func (t *UDPTracer) performHop(target *net.UDPAddr, ttl int, port int, icmpConn *icmp.PacketConn, probesPerHop int, retries int) HopResult {
+	probes := make([]ProbeResult, probesPerHop)
+	for i := range probes {
+		probes[i].Probe = i + 1
+		probes[i].Timeout = true
+	}
 
-	// Create UDP socket with specific TTL
-	udpConn, err := net.DialUDP("udp4", nil, &net.UDPAddr{
-		IP:   target.IP,
-		Port: port,
-	})
-	if err != nil {
-		return HopResult{
-			Hop:     ttl,
-			RTT:     time.Since(start),
-			Success: false,
-			Error:   fmt.Errorf("failed to create UDP connection: %w", err),
+	for round := 0; round <= retries; round++ {
+		if !t.sendAndCollectProbes(target, ttl, port, icmpConn, probes) {
+			break
 		}
 	}
-	defer udpConn.Close()
-
-	// Set TTL on the UDP socket
-	if err := t.setTTL(udpConn, ttl); err != nil {
-		return HopResult{
-			Hop:     ttl,
-			RTT:     time.Since(start),
-			Success: false,
-			Error:   fmt.Errorf("failed to set TTL: %w", err),
+
+	return summarizeProbes(ttl, probes)
+}
+
+// sendAndCollectProbes sends one UDP packet per probe still marked as a
+// timeout, then reads ICMP responses until every outstanding probe is
+// matched or hopTimeout elapses. It returns true if any probe is still
+// unanswered afterwards, so the caller knows whether a retry is worthwhile.
+func (t *UDPTracer) sendAndCollectProbes(target *net.UDPAddr, ttl int, port int, icmpConn *icmp.PacketConn, probes []ProbeResult) bool {
+	pending := make(map[int]int) // local port -> probe index
+	sentAt := make(map[int]time.Time)
+
+	for i := range probes {
+		if !probes[i].Timeout {
+			continue
 		}
-	}
 
-	// Set timeout for ICMP response
-	icmpConn.SetReadDeadline(time.Now().Add(t.hopTimeout))
+		probeStart := time.Now()
+		udpConn, err := net.DialUDP("udp4", nil, &net.UDPAddr{
+			IP:   target.IP,
+			Port: port,
+		})
+		if err != nil {
+			continue
+		}
 
-	// Send UDP packet
-	_, err = udpConn.Write([]byte("traceroute-probe"))
-	if err != nil {
-		return HopResult{
-			Hop:     ttl,
-			RTT:     time.Since(start),
-			Success: false,
-			Error:   fmt.Errorf("failed to send UDP packet: %w", err),
+		if err := t.setTTL(udpConn, ttl); err != nil {
+			udpConn.Close()
+			continue
+		}
+
+		localPort := udpConn.LocalAddr().(*net.UDPAddr).Port
+
+		_, err = udpConn.Write([]byte("traceroute-probe"))
+		udpConn.Close()
+		if err != nil {
+			continue
 		}
+
+		pending[localPort] = i
+		sentAt[localPort] = probeStart
+	}
+
+	if len(pending) == 0 {
+		return false
 	}
 
-	// Listen for ICMP response
+	deadline := time.Now().Add(t.hopTimeout)
 	buffer := make([]byte, 1500)
-	n, peer, err := icmpConn.ReadFrom(buffer)
-	rtt := time.Since(start)
 
-	if err != nil {
-		// Check if it's a timeout
-		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-			return HopResult{
-				Hop:     ttl,
-				RTT:     rtt,
-				Success: false,
-				Timeout: true,
-			}
+	for len(pending) > 0 {
+		if time.Now().After(deadline) {
+			break
 		}
-		return HopResult{
-			Hop:     ttl,
-			RTT:     rtt,
-			Success: false,
-			Error:   fmt.Errorf("ICMP read error: %w", err),
+		icmpConn.SetReadDeadline(deadline)
+
+		n, peer, err := icmpConn.ReadFrom(buffer)
+		if err != nil {
+			break // timeout or read error: whatever is left in pending stays a timeout
+		}
+		if n < 8 || (buffer[0] != 11 && buffer[0] != 3) { // Time Exceeded or Destination Unreachable
+			continue
 		}
-	}
 
-	// Parse ICMP message (basic validation)
-	if n < 8 {
-		return HopResult{
-			Hop:     ttl,
-			RTT:     rtt,
-			Success: false,
-			Error:   fmt.Errorf("ICMP message too short"),
+		ident, err := parseEmbeddedProbe(buffer[:n], protocolUDP)
+		if err != nil {
+			continue
+		}
+		idx, ok := pending[ident.srcPort]
+		if !ok {
+			continue // reply to a different in-flight probe
 		}
-	}
 
-	// Basic ICMP header validation
-	icmpType := buffer[0]
-	if icmpType != 11 && icmpType != 3 { // Time Exceeded or Destination Unreachable
-		return HopResult{
-			Hop:     ttl,
-			RTT:     rtt,
-			Success: false,
-			Error:   fmt.Errorf("unexpected ICMP type: %d", icmpType),
+		var hopIP net.IP
+		if peerAddr, ok := peer.(*net.IPAddr); ok {
+			hopIP = peerAddr.IP
 		}
-	}
 
-	// Extract source IP from peer address
-	var hopIP net.IP
-	if peerAddr, ok := peer.(*net.IPAddr); ok {
-		hopIP = peerAddr.IP
-	}
+		probes[idx].IP = hopIP
+		probes[idx].RTT = time.Since(sentAt[ident.srcPort])
+		probes[idx].Success = true
+		probes[idx].Timeout = false
 
-	return HopResult{
-		Hop:     ttl,
-		IP:      hopIP,
-		RTT:     rtt,
-		Success: true,
-		Timeout: false,
+		delete(pending, ident.srcPort)
 	}
+
+	return len(pending) > 0
 }
 
-// setTTL sets the TTL on a UDP connection
+// setTTL sets the TTL on the outgoing UDP socket so that the kernel
+// actually drops the packet at the target hop and elicits an ICMP Time
+// Exceeded from it, instead of letting every probe reach the destination.
 func (t *UDPTracer) setTTL(conn *net.UDPConn, ttl int) error {
 	if runtime.GOOS == "windows" {
 		return fmt.Errorf("traceroute not supported on Windows - use Linux or macOS")
 	}
 
-	// For now, just return success - TTL setting requires platform-specific implementation
-	// In production, this would use syscall.SetsockoptInt with proper platform handling
-	return nil
+	return ipv4.NewConn(conn).SetTTL(ttl)
 }